@@ -0,0 +1,687 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+// Wire-compatible with the TCompactProtocol implementation shipped by
+// apache/thrift's Go (and C++/Java/Python) runtimes, so payloads produced
+// here can be read by, and payloads from, any other Thrift Compact
+// implementation.
+const (
+	compactProtocolID      byte = 0x82
+	compactVersion         byte = 1
+	compactVersionMask     byte = 0x1f
+	compactTypeMask        byte = 0xe0
+	compactTypeShiftAmount      = 5
+)
+
+// Compact protocol type tags, distinct from types.Type: BOOL is split into
+// two tags so a field's value is folded into the field-header byte instead
+// of costing its own byte on the wire.
+const (
+	compactStop         byte = 0x00
+	compactBooleanTrue  byte = 0x01
+	compactBooleanFalse byte = 0x02
+	compactByte         byte = 0x03
+	compactI16          byte = 0x04
+	compactI32          byte = 0x05
+	compactI64          byte = 0x06
+	compactDouble       byte = 0x07
+	compactBinary       byte = 0x08
+	compactList         byte = 0x09
+	compactSet          byte = 0x0a
+	compactMap          byte = 0x0b
+	compactStruct       byte = 0x0c
+	compactFloat        byte = 0x0d
+)
+
+func ttypeToCompactType(t types.Type) (byte, error) {
+	switch t {
+	case types.STOP:
+		return compactStop, nil
+	case types.BOOL:
+		return compactBooleanTrue, nil
+	case types.BYTE:
+		return compactByte, nil
+	case types.I16:
+		return compactI16, nil
+	case types.I32:
+		return compactI32, nil
+	case types.I64:
+		return compactI64, nil
+	case types.DOUBLE:
+		return compactDouble, nil
+	case types.FLOAT:
+		return compactFloat, nil
+	case types.STRING:
+		return compactBinary, nil
+	case types.LIST:
+		return compactList, nil
+	case types.SET:
+		return compactSet, nil
+	case types.MAP:
+		return compactMap, nil
+	case types.STRUCT:
+		return compactStruct, nil
+	default:
+		return 0, types.NewProtocolExceptionWithType(types.INVALID_DATA, errors.New("compact: unknown type"))
+	}
+}
+
+func compactTypeToTType(t byte) (types.Type, error) {
+	switch t {
+	case compactStop:
+		return types.STOP, nil
+	case compactBooleanTrue, compactBooleanFalse:
+		return types.BOOL, nil
+	case compactByte:
+		return types.BYTE, nil
+	case compactI16:
+		return types.I16, nil
+	case compactI32:
+		return types.I32, nil
+	case compactI64:
+		return types.I64, nil
+	case compactDouble:
+		return types.DOUBLE, nil
+	case compactFloat:
+		return types.FLOAT, nil
+	case compactBinary:
+		return types.STRING, nil
+	case compactList:
+		return types.LIST, nil
+	case compactSet:
+		return types.SET, nil
+	case compactMap:
+		return types.MAP, nil
+	case compactStruct:
+		return types.STRUCT, nil
+	default:
+		return types.STOP, types.NewProtocolExceptionWithType(types.INVALID_DATA, errors.New("compact: unknown compact type tag"))
+	}
+}
+
+// compactField is the state tracked per open struct, so nested structs
+// restore their parent's field-ID delta base on ReadStructEnd/WriteStructEnd.
+type compactField struct {
+	lastFieldID int16
+}
+
+// CompactProtocol is a types.Format implementing Thrift's Compact Protocol:
+// varint-encoded integers, ZigZag signed integers, struct field IDs stored
+// as a 4-bit delta from the previously written/read field, and bool values
+// folded into the field-header byte.
+type CompactProtocol struct {
+	trans types.ReadWriteSizer
+
+	fieldStack  []compactField
+	lastFieldID int16
+
+	// Deferred write of a field header for a bool value: the compact
+	// type tag (true/false) is only known once WriteBool is called, so
+	// WriteFieldBegin just remembers the field ID.
+	booleanFieldPending bool
+	booleanFieldID      int16
+
+	// Bool value read as part of ReadFieldBegin's header byte, consumed
+	// by the following ReadBool() instead of a byte of its own.
+	boolValuePending bool
+	boolValue        bool
+
+	// maxStringLength, when positive, bounds a single ReadBinary's
+	// declared length; it's rejected before the buffer for it is
+	// allocated. Set via SetMaxStringLength, e.g. by
+	// types.NewGuardedDecoder.
+	maxStringLength int
+}
+
+// NewCompactProtocol returns a Format that reads and writes the Thrift
+// Compact Protocol wire format on top of rw.
+func NewCompactProtocol(rw types.ReadWriteSizer) *CompactProtocol {
+	return &CompactProtocol{trans: rw}
+}
+
+// SetMaxStringLength bounds the declared length ReadBinary/ReadString will
+// accept; a length over n is rejected before allocating a buffer for it.
+// n <= 0 means no limit.
+func (p *CompactProtocol) SetMaxStringLength(n int) {
+	p.maxStringLength = n
+}
+
+// Len returns the number of unread bytes left in the underlying
+// ReadWriteSizer, so types.NewGuardedDecoder can count bytes consumed.
+func (p *CompactProtocol) Len() int {
+	return p.trans.Len()
+}
+
+var _ types.Format = (*CompactProtocol)(nil)
+
+// --- message ---
+
+func (p *CompactProtocol) WriteMessageBegin(name string, typeID types.MessageType, seqid int32) error {
+	if err := writeByte(p.trans, compactProtocolID); err != nil {
+		return types.NewProtocolException(err)
+	}
+	if err := writeByte(p.trans, compactVersion&compactVersionMask|byte(typeID)<<compactTypeShiftAmount); err != nil {
+		return types.NewProtocolException(err)
+	}
+	if err := p.writeVarint32(seqid); err != nil {
+		return err
+	}
+	return p.WriteString(name)
+}
+
+func (p *CompactProtocol) WriteMessageEnd() error { return nil }
+
+func (p *CompactProtocol) ReadMessageBegin() (name string, typeID types.MessageType, seqid int32, err error) {
+	protocolID, err := readByte(p.trans)
+	if err != nil {
+		return "", 0, 0, types.NewProtocolException(err)
+	}
+	if protocolID != compactProtocolID {
+		return "", 0, 0, types.NewProtocolExceptionWithType(types.BAD_VERSION, errors.New("compact: bad protocol id"))
+	}
+	versionAndType, err := readByte(p.trans)
+	if err != nil {
+		return "", 0, 0, types.NewProtocolException(err)
+	}
+	if versionAndType&compactVersionMask != compactVersion {
+		return "", 0, 0, types.NewProtocolExceptionWithType(types.BAD_VERSION, errors.New("compact: bad version"))
+	}
+	typeID = types.MessageType((versionAndType & compactTypeMask) >> compactTypeShiftAmount)
+	seqid, err = p.readVarint32()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	name, err = p.ReadString()
+	return name, typeID, seqid, err
+}
+
+func (p *CompactProtocol) ReadMessageEnd() error { return nil }
+
+// --- struct ---
+
+func (p *CompactProtocol) WriteStructBegin(name string) error {
+	p.fieldStack = append(p.fieldStack, compactField{lastFieldID: p.lastFieldID})
+	p.lastFieldID = 0
+	return nil
+}
+
+func (p *CompactProtocol) WriteStructEnd() error {
+	last := len(p.fieldStack) - 1
+	p.lastFieldID = p.fieldStack[last].lastFieldID
+	p.fieldStack = p.fieldStack[:last]
+	return nil
+}
+
+func (p *CompactProtocol) ReadStructBegin() (name string, err error) {
+	p.fieldStack = append(p.fieldStack, compactField{lastFieldID: p.lastFieldID})
+	p.lastFieldID = 0
+	return "", nil
+}
+
+func (p *CompactProtocol) ReadStructEnd() error {
+	last := len(p.fieldStack) - 1
+	p.lastFieldID = p.fieldStack[last].lastFieldID
+	p.fieldStack = p.fieldStack[:last]
+	return nil
+}
+
+// --- field ---
+
+func (p *CompactProtocol) WriteFieldBegin(name string, typeID types.Type, id int16) error {
+	if typeID == types.BOOL {
+		// The compact type tag for a bool field doubles as its value,
+		// so the header can't be written until WriteBool supplies it.
+		p.booleanFieldPending = true
+		p.booleanFieldID = id
+		return nil
+	}
+	compactType, err := ttypeToCompactType(typeID)
+	if err != nil {
+		return err
+	}
+	return p.writeFieldHeader(compactType, id)
+}
+
+func (p *CompactProtocol) writeFieldHeader(compactType byte, id int16) error {
+	delta := id - p.lastFieldID
+	if delta > 0 && delta <= 15 {
+		if err := writeByte(p.trans, byte(delta)<<4|compactType); err != nil {
+			return types.NewProtocolException(err)
+		}
+	} else {
+		if err := writeByte(p.trans, compactType); err != nil {
+			return types.NewProtocolException(err)
+		}
+		if err := p.WriteI16(id); err != nil {
+			return err
+		}
+	}
+	p.lastFieldID = id
+	return nil
+}
+
+func (p *CompactProtocol) WriteFieldEnd() error { return nil }
+
+func (p *CompactProtocol) WriteFieldStop() error {
+	if err := writeByte(p.trans, compactStop); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadFieldBegin() (name string, typeID types.Type, id int16, err error) {
+	b, err := readByte(p.trans)
+	if err != nil {
+		return "", 0, 0, types.NewProtocolException(err)
+	}
+	if b == compactStop {
+		return "", types.STOP, 0, nil
+	}
+	compactType := b & 0x0f
+	modifier := (b & 0xf0) >> 4
+	if modifier == 0 {
+		id, err = p.ReadI16()
+		if err != nil {
+			return "", 0, 0, err
+		}
+	} else {
+		id = p.lastFieldID + int16(modifier)
+	}
+	typeID, err = compactTypeToTType(compactType)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if compactType == compactBooleanTrue || compactType == compactBooleanFalse {
+		p.boolValuePending = true
+		p.boolValue = compactType == compactBooleanTrue
+	}
+	p.lastFieldID = id
+	return "", typeID, id, nil
+}
+
+func (p *CompactProtocol) ReadFieldEnd() error { return nil }
+
+// --- bool (folded into the field header) ---
+
+func (p *CompactProtocol) WriteBool(value bool) error {
+	if p.booleanFieldPending {
+		compactType := compactBooleanFalse
+		if value {
+			compactType = compactBooleanTrue
+		}
+		p.booleanFieldPending = false
+		return p.writeFieldHeader(compactType, p.booleanFieldID)
+	}
+	// Outside of a struct field (e.g. list/set elements), bools still
+	// cost a full byte.
+	b := byte(compactBooleanFalse)
+	if value {
+		b = compactBooleanTrue
+	}
+	if err := writeByte(p.trans, b); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadBool() (value bool, err error) {
+	if p.boolValuePending {
+		p.boolValuePending = false
+		return p.boolValue, nil
+	}
+	b, err := readByte(p.trans)
+	if err != nil {
+		return false, types.NewProtocolException(err)
+	}
+	return b == compactBooleanTrue, nil
+}
+
+// --- scalars ---
+
+func (p *CompactProtocol) WriteByte(value byte) error {
+	if err := writeByte(p.trans, value); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadByte() (value byte, err error) {
+	value, err = readByte(p.trans)
+	if err != nil {
+		return 0, types.NewProtocolException(err)
+	}
+	return value, nil
+}
+
+func (p *CompactProtocol) WriteI16(value int16) error {
+	return p.writeVarint64(zigzagFromInt64(int64(value)))
+}
+
+func (p *CompactProtocol) ReadI16() (value int16, err error) {
+	v, err := p.readVarint64()
+	if err != nil {
+		return 0, err
+	}
+	return int16(zigzagToInt64(v)), nil
+}
+
+func (p *CompactProtocol) WriteI32(value int32) error {
+	return p.writeVarint64(zigzagFromInt64(int64(value)))
+}
+
+func (p *CompactProtocol) ReadI32() (value int32, err error) {
+	v, err := p.readVarint64()
+	if err != nil {
+		return 0, err
+	}
+	return int32(zigzagToInt64(v)), nil
+}
+
+func (p *CompactProtocol) writeVarint32(value int32) error {
+	return p.writeVarint64(uint64(uint32(value)))
+}
+
+func (p *CompactProtocol) readVarint32() (int32, error) {
+	v, err := p.readVarint64()
+	if err != nil {
+		return 0, err
+	}
+	return int32(uint32(v)), nil
+}
+
+func (p *CompactProtocol) WriteI64(value int64) error {
+	return p.writeVarint64(zigzagFromInt64(value))
+}
+
+func (p *CompactProtocol) ReadI64() (value int64, err error) {
+	v, err := p.readVarint64()
+	if err != nil {
+		return 0, err
+	}
+	return zigzagToInt64(v), nil
+}
+
+func (p *CompactProtocol) WriteDouble(value float64) error {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(value))
+	if _, err := p.trans.Write(buf[:]); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadDouble() (value float64, err error) {
+	var buf [8]byte
+	if _, err := readFull(p.trans, buf[:]); err != nil {
+		return 0, types.NewProtocolException(err)
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+func (p *CompactProtocol) WriteFloat(value float32) error {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], math.Float32bits(value))
+	if _, err := p.trans.Write(buf[:]); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadFloat() (value float32, err error) {
+	var buf [4]byte
+	if _, err := readFull(p.trans, buf[:]); err != nil {
+		return 0, types.NewProtocolException(err)
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(buf[:])), nil
+}
+
+func (p *CompactProtocol) WriteString(value string) error {
+	return p.WriteBinary([]byte(value))
+}
+
+func (p *CompactProtocol) ReadString() (value string, err error) {
+	b, err := p.ReadBinary()
+	return string(b), err
+}
+
+func (p *CompactProtocol) WriteBinary(value []byte) error {
+	if err := p.writeVarint32(int32(len(value))); err != nil {
+		return err
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	if _, err := p.trans.Write(value); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) ReadBinary() (value []byte, err error) {
+	size, err := p.readVarint32()
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, types.NewProtocolExceptionWithType(types.NEGATIVE_SIZE, errors.New("compact: negative binary size"))
+	}
+	if p.maxStringLength > 0 && int(size) > p.maxStringLength {
+		return nil, types.NewProtocolExceptionWithType(types.SIZE_LIMIT, errors.New("compact: binary size exceeds max string length"))
+	}
+	if size == 0 {
+		return []byte{}, nil
+	}
+	buf := make([]byte, size)
+	if _, err := readFull(p.trans, buf); err != nil {
+		return nil, types.NewProtocolException(err)
+	}
+	return buf, nil
+}
+
+// --- containers ---
+
+// compactContainerSizeLimit is the largest size that packs into the high
+// nibble of a single list/set header byte; larger sizes spill into a
+// following varint.
+const compactContainerSizeLimit = 14
+
+func (p *CompactProtocol) WriteListBegin(elemType types.Type, size int) error {
+	return p.writeCollectionBegin(elemType, size)
+}
+
+func (p *CompactProtocol) WriteListEnd() error { return nil }
+
+func (p *CompactProtocol) WriteSetBegin(elemType types.Type, size int) error {
+	return p.writeCollectionBegin(elemType, size)
+}
+
+func (p *CompactProtocol) WriteSetEnd() error { return nil }
+
+func (p *CompactProtocol) writeCollectionBegin(elemType types.Type, size int) error {
+	compactType, err := ttypeToCompactType(elemType)
+	if err != nil {
+		return err
+	}
+	if size <= compactContainerSizeLimit {
+		if err := writeByte(p.trans, byte(size)<<4|compactType); err != nil {
+			return types.NewProtocolException(err)
+		}
+		return nil
+	}
+	if err := writeByte(p.trans, 0xf0|compactType); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return p.writeVarint32(int32(size))
+}
+
+func (p *CompactProtocol) ReadListBegin() (elemType types.Type, size int, err error) {
+	return p.readCollectionBegin()
+}
+
+func (p *CompactProtocol) ReadListEnd() error { return nil }
+
+func (p *CompactProtocol) ReadSetBegin() (elemType types.Type, size int, err error) {
+	return p.readCollectionBegin()
+}
+
+func (p *CompactProtocol) ReadSetEnd() error { return nil }
+
+func (p *CompactProtocol) readCollectionBegin() (elemType types.Type, size int, err error) {
+	b, err := readByte(p.trans)
+	if err != nil {
+		return 0, 0, types.NewProtocolException(err)
+	}
+	elemType, err = compactTypeToTType(b & 0x0f)
+	if err != nil {
+		return 0, 0, err
+	}
+	sizeNibble := int(b&0xf0) >> 4
+	if sizeNibble != 0x0f {
+		return elemType, sizeNibble, nil
+	}
+	n, err := p.readVarint32()
+	if err != nil {
+		return 0, 0, err
+	}
+	if n < 0 {
+		return 0, 0, types.NewProtocolExceptionWithType(types.NEGATIVE_SIZE, errors.New("compact: negative container size"))
+	}
+	return elemType, int(n), nil
+}
+
+func (p *CompactProtocol) WriteMapBegin(keyType types.Type, valueType types.Type, size int) error {
+	if size == 0 {
+		return p.WriteByte(0)
+	}
+	if err := p.writeVarint32(int32(size)); err != nil {
+		return err
+	}
+	compactKeyType, err := ttypeToCompactType(keyType)
+	if err != nil {
+		return err
+	}
+	compactValueType, err := ttypeToCompactType(valueType)
+	if err != nil {
+		return err
+	}
+	if err := writeByte(p.trans, compactKeyType<<4|compactValueType); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+func (p *CompactProtocol) WriteMapEnd() error { return nil }
+
+func (p *CompactProtocol) ReadMapBegin() (keyType types.Type, valueType types.Type, size int, err error) {
+	n, err := p.readVarint32()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if n < 0 {
+		return 0, 0, 0, types.NewProtocolExceptionWithType(types.NEGATIVE_SIZE, errors.New("compact: negative map size"))
+	}
+	if n == 0 {
+		return types.STOP, types.STOP, 0, nil
+	}
+	b, err := readByte(p.trans)
+	if err != nil {
+		return 0, 0, 0, types.NewProtocolException(err)
+	}
+	keyType, err = compactTypeToTType(b & 0xf0 >> 4)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	valueType, err = compactTypeToTType(b & 0x0f)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return keyType, valueType, int(n), nil
+}
+
+func (p *CompactProtocol) ReadMapEnd() error { return nil }
+
+func (p *CompactProtocol) Skip(fieldType types.Type) error {
+	return types.SkipDefaultDepth(p, fieldType)
+}
+
+func (p *CompactProtocol) Flush() error {
+	return flush(p.trans)
+}
+
+// --- varint/zigzag helpers ---
+
+func zigzagFromInt64(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func zigzagToInt64(n uint64) int64 {
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+func (p *CompactProtocol) writeVarint64(n uint64) error {
+	for {
+		if n&^0x7f == 0 {
+			return p.WriteByte(byte(n))
+		}
+		if err := p.WriteByte(byte(n&0x7f | 0x80)); err != nil {
+			return err
+		}
+		n >>= 7
+	}
+}
+
+func (p *CompactProtocol) readVarint64() (uint64, error) {
+	var result uint64
+	var shift uint
+	for {
+		b, err := readByte(p.trans)
+		if err != nil {
+			return 0, types.NewProtocolException(err)
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+		shift += 7
+		if shift >= 64 {
+			return 0, types.NewProtocolExceptionWithType(types.INVALID_DATA, errors.New("compact: varint too long"))
+		}
+	}
+}
+
+func readFull(r interface {
+	Read(p []byte) (int, error)
+}, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
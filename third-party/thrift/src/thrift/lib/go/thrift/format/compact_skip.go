@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"errors"
+	"io"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+var _ types.FastSkipper = (*CompactProtocol)(nil)
+
+// SkipList implements types.FastSkipper. BOOL/BYTE/DOUBLE/FLOAT are
+// fixed-width on the wire, so the whole run is discarded in one call
+// instead of one ReadBool/ReadByte/etc. per element; I16/I32/I64 are
+// varint-encoded, so they're skipped with a batched varint scan that still
+// avoids the per-element Skip/ReadFieldBegin dispatch.
+func (p *CompactProtocol) SkipList(elemType types.Type, size int) error {
+	switch elemType {
+	case types.BOOL, types.BYTE:
+		return p.SkipBytes(size)
+	case types.DOUBLE:
+		return p.SkipBytes(size * 8)
+	case types.FLOAT:
+		return p.SkipBytes(size * 4)
+	case types.I16, types.I32, types.I64:
+		for i := 0; i < size; i++ {
+			if _, err := p.readVarint64(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return types.NewProtocolExceptionWithType(types.NOT_IMPLEMENTED, errNotFixedWidth)
+	}
+}
+
+// SkipBytes implements types.FastSkipper by discarding n raw bytes in bulk
+// rather than one byte at a time.
+func (p *CompactProtocol) SkipBytes(n int) error {
+	if n == 0 {
+		return nil
+	}
+	if _, err := io.CopyN(io.Discard, p.trans, int64(n)); err != nil {
+		return types.NewProtocolException(err)
+	}
+	return nil
+}
+
+var errNotFixedWidth = errors.New("compact: SkipList only supports fixed-width scalar element types")
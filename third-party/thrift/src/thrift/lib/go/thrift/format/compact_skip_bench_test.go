@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+const skipBenchListSize = 1_000_000
+
+func encodeI64List(n int) []byte {
+	var buf bytes.Buffer
+	p := NewCompactProtocol(&buf)
+	if err := p.WriteListBegin(types.I64, n); err != nil {
+		panic(err)
+	}
+	for i := 0; i < n; i++ {
+		if err := p.WriteI64(int64(i)); err != nil {
+			panic(err)
+		}
+	}
+	if err := p.WriteListEnd(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkSkipListI64FastPath skips a list<i64> of 1M elements through
+// CompactProtocol's FastSkipper fast path (format.CompactProtocol.SkipList).
+func BenchmarkSkipListI64FastPath(b *testing.B) {
+	data := encodeI64List(skipBenchListSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := NewCompactProtocol(bytes.NewBuffer(data))
+		if err := types.SkipDefaultDepth(p, types.LIST); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// noFastSkipDecoder hides CompactProtocol's FastSkipper behind a plain
+// types.Decoder so types.Skip falls back to its per-element loop, giving a
+// baseline for BenchmarkSkipListI64FastPath to compare against.
+type noFastSkipDecoder struct {
+	types.Decoder
+}
+
+// BenchmarkSkipListI64PerElement skips the same payload with the
+// FastSkipper fast path hidden, issuing one ReadI64 per element the way
+// Skip did before FastSkipper existed.
+func BenchmarkSkipListI64PerElement(b *testing.B) {
+	data := encodeI64List(skipBenchListSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := NewCompactProtocol(bytes.NewBuffer(data))
+		d := noFastSkipDecoder{Decoder: p}
+		if err := types.SkipDefaultDepth(d, types.LIST); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+func TestCompactProtocolMessageRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	wantType := types.MessageType(1) // call, per the Thrift wire protocol's message type enum
+	w := NewCompactProtocol(&buf)
+	if err := w.WriteMessageBegin("getFoo", wantType, 42); err != nil {
+		t.Fatalf("WriteMessageBegin: %v", err)
+	}
+	if err := w.WriteMessageEnd(); err != nil {
+		t.Fatalf("WriteMessageEnd: %v", err)
+	}
+
+	r := NewCompactProtocol(&buf)
+	name, typeID, seqid, err := r.ReadMessageBegin()
+	if err != nil {
+		t.Fatalf("ReadMessageBegin: %v", err)
+	}
+	if name != "getFoo" || typeID != wantType || seqid != 42 {
+		t.Fatalf("got (%q, %v, %d), want (%q, %v, %d)", name, typeID, seqid, "getFoo", wantType, 42)
+	}
+}
+
+func TestCompactProtocolFieldIDDeltaRoundTrip(t *testing.T) {
+	// Field IDs 1, 2, 20 exercise both the packed 4-bit delta (1->2) and
+	// the fallback to a full I16 write (2->20, delta 18 > 15).
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	if err := w.WriteStructBegin("S"); err != nil {
+		t.Fatalf("WriteStructBegin: %v", err)
+	}
+	ids := []int16{1, 2, 20}
+	for _, id := range ids {
+		if err := w.WriteFieldBegin("", types.I32, id); err != nil {
+			t.Fatalf("WriteFieldBegin(%d): %v", id, err)
+		}
+		if err := w.WriteI32(int32(id) * 10); err != nil {
+			t.Fatalf("WriteI32: %v", err)
+		}
+		if err := w.WriteFieldEnd(); err != nil {
+			t.Fatalf("WriteFieldEnd: %v", err)
+		}
+	}
+	if err := w.WriteFieldStop(); err != nil {
+		t.Fatalf("WriteFieldStop: %v", err)
+	}
+	if err := w.WriteStructEnd(); err != nil {
+		t.Fatalf("WriteStructEnd: %v", err)
+	}
+
+	r := NewCompactProtocol(&buf)
+	if _, err := r.ReadStructBegin(); err != nil {
+		t.Fatalf("ReadStructBegin: %v", err)
+	}
+	for _, wantID := range ids {
+		_, typeID, id, err := r.ReadFieldBegin()
+		if err != nil {
+			t.Fatalf("ReadFieldBegin: %v", err)
+		}
+		if typeID != types.I32 || id != wantID {
+			t.Fatalf("got (type=%v, id=%d), want (type=%v, id=%d)", typeID, id, types.I32, wantID)
+		}
+		v, err := r.ReadI32()
+		if err != nil {
+			t.Fatalf("ReadI32: %v", err)
+		}
+		if v != int32(wantID)*10 {
+			t.Fatalf("value = %d, want %d", v, int32(wantID)*10)
+		}
+		if err := r.ReadFieldEnd(); err != nil {
+			t.Fatalf("ReadFieldEnd: %v", err)
+		}
+	}
+	_, typeID, _, err := r.ReadFieldBegin()
+	if err != nil {
+		t.Fatalf("ReadFieldBegin (stop): %v", err)
+	}
+	if typeID != types.STOP {
+		t.Fatalf("got type %v, want STOP", typeID)
+	}
+	if err := r.ReadStructEnd(); err != nil {
+		t.Fatalf("ReadStructEnd: %v", err)
+	}
+}
+
+func TestCompactProtocolBoolFieldFoldedIntoHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	if err := w.WriteStructBegin("S"); err != nil {
+		t.Fatalf("WriteStructBegin: %v", err)
+	}
+	if err := w.WriteFieldBegin("", types.BOOL, 1); err != nil {
+		t.Fatalf("WriteFieldBegin: %v", err)
+	}
+	if err := w.WriteBool(true); err != nil {
+		t.Fatalf("WriteBool: %v", err)
+	}
+	if err := w.WriteFieldStop(); err != nil {
+		t.Fatalf("WriteFieldStop: %v", err)
+	}
+	if err := w.WriteStructEnd(); err != nil {
+		t.Fatalf("WriteStructEnd: %v", err)
+	}
+
+	// A bool field costs exactly one byte on the wire (the header), not a
+	// header plus a value byte.
+	if buf.Len() != 2 { // field header + stop byte
+		t.Fatalf("encoded length = %d, want 2 (folded bool header + stop)", buf.Len())
+	}
+
+	r := NewCompactProtocol(&buf)
+	if _, err := r.ReadStructBegin(); err != nil {
+		t.Fatalf("ReadStructBegin: %v", err)
+	}
+	_, typeID, id, err := r.ReadFieldBegin()
+	if err != nil {
+		t.Fatalf("ReadFieldBegin: %v", err)
+	}
+	if typeID != types.BOOL || id != 1 {
+		t.Fatalf("got (type=%v, id=%d), want (BOOL, 1)", typeID, id)
+	}
+	v, err := r.ReadBool()
+	if err != nil {
+		t.Fatalf("ReadBool: %v", err)
+	}
+	if !v {
+		t.Fatal("ReadBool = false, want true")
+	}
+}
+
+func TestCompactProtocolScalarRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	wantByte := byte(0xAB)
+	wantI16 := int16(-1234)
+	wantI32 := int32(-123456789)
+	wantI64 := int64(-1234567890123456789)
+	wantDouble := 3.14159265358979
+	wantFloat := float32(2.71828)
+	wantString := "hello, compact"
+
+	if err := w.WriteByte(wantByte); err != nil {
+		t.Fatalf("WriteByte: %v", err)
+	}
+	if err := w.WriteI16(wantI16); err != nil {
+		t.Fatalf("WriteI16: %v", err)
+	}
+	if err := w.WriteI32(wantI32); err != nil {
+		t.Fatalf("WriteI32: %v", err)
+	}
+	if err := w.WriteI64(wantI64); err != nil {
+		t.Fatalf("WriteI64: %v", err)
+	}
+	if err := w.WriteDouble(wantDouble); err != nil {
+		t.Fatalf("WriteDouble: %v", err)
+	}
+	if err := w.WriteFloat(wantFloat); err != nil {
+		t.Fatalf("WriteFloat: %v", err)
+	}
+	if err := w.WriteString(wantString); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+
+	r := NewCompactProtocol(&buf)
+	if v, err := r.ReadByte(); err != nil || v != wantByte {
+		t.Fatalf("ReadByte = (%v, %v), want %v", v, err, wantByte)
+	}
+	if v, err := r.ReadI16(); err != nil || v != wantI16 {
+		t.Fatalf("ReadI16 = (%v, %v), want %v", v, err, wantI16)
+	}
+	if v, err := r.ReadI32(); err != nil || v != wantI32 {
+		t.Fatalf("ReadI32 = (%v, %v), want %v", v, err, wantI32)
+	}
+	if v, err := r.ReadI64(); err != nil || v != wantI64 {
+		t.Fatalf("ReadI64 = (%v, %v), want %v", v, err, wantI64)
+	}
+	if v, err := r.ReadDouble(); err != nil || v != wantDouble {
+		t.Fatalf("ReadDouble = (%v, %v), want %v", v, err, wantDouble)
+	}
+	if v, err := r.ReadFloat(); err != nil || v != wantFloat {
+		t.Fatalf("ReadFloat = (%v, %v), want %v", v, err, wantFloat)
+	}
+	if v, err := r.ReadString(); err != nil || v != wantString {
+		t.Fatalf("ReadString = (%q, %v), want %q", v, err, wantString)
+	}
+}
+
+func TestCompactProtocolListRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 3, compactContainerSizeLimit, compactContainerSizeLimit + 1, 100} {
+		var buf bytes.Buffer
+		w := NewCompactProtocol(&buf)
+		if err := w.WriteListBegin(types.I32, size); err != nil {
+			t.Fatalf("size=%d WriteListBegin: %v", size, err)
+		}
+		for i := 0; i < size; i++ {
+			if err := w.WriteI32(int32(i)); err != nil {
+				t.Fatalf("size=%d WriteI32(%d): %v", size, i, err)
+			}
+		}
+		if err := w.WriteListEnd(); err != nil {
+			t.Fatalf("size=%d WriteListEnd: %v", size, err)
+		}
+
+		r := NewCompactProtocol(&buf)
+		elemType, gotSize, err := r.ReadListBegin()
+		if err != nil {
+			t.Fatalf("size=%d ReadListBegin: %v", size, err)
+		}
+		if elemType != types.I32 || gotSize != size {
+			t.Fatalf("size=%d got (elemType=%v, size=%d), want (I32, %d)", size, elemType, gotSize, size)
+		}
+		for i := 0; i < size; i++ {
+			v, err := r.ReadI32()
+			if err != nil {
+				t.Fatalf("size=%d ReadI32(%d): %v", size, i, err)
+			}
+			if v != int32(i) {
+				t.Fatalf("size=%d element %d = %d, want %d", size, i, v, i)
+			}
+		}
+		if err := r.ReadListEnd(); err != nil {
+			t.Fatalf("size=%d ReadListEnd: %v", size, err)
+		}
+	}
+}
+
+func TestCompactProtocolMapRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	if err := w.WriteMapBegin(types.STRING, types.I32, 2); err != nil {
+		t.Fatalf("WriteMapBegin: %v", err)
+	}
+	if err := w.WriteString("a"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if err := w.WriteI32(1); err != nil {
+		t.Fatalf("WriteI32: %v", err)
+	}
+	if err := w.WriteString("b"); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	if err := w.WriteI32(2); err != nil {
+		t.Fatalf("WriteI32: %v", err)
+	}
+	if err := w.WriteMapEnd(); err != nil {
+		t.Fatalf("WriteMapEnd: %v", err)
+	}
+
+	r := NewCompactProtocol(&buf)
+	keyType, valueType, size, err := r.ReadMapBegin()
+	if err != nil {
+		t.Fatalf("ReadMapBegin: %v", err)
+	}
+	if keyType != types.STRING || valueType != types.I32 || size != 2 {
+		t.Fatalf("got (key=%v, value=%v, size=%d), want (STRING, I32, 2)", keyType, valueType, size)
+	}
+	for _, want := range []struct {
+		key string
+		val int32
+	}{{"a", 1}, {"b", 2}} {
+		k, err := r.ReadString()
+		if err != nil || k != want.key {
+			t.Fatalf("ReadString = (%q, %v), want %q", k, err, want.key)
+		}
+		v, err := r.ReadI32()
+		if err != nil || v != want.val {
+			t.Fatalf("ReadI32 = (%d, %v), want %d", v, err, want.val)
+		}
+	}
+	if err := r.ReadMapEnd(); err != nil {
+		t.Fatalf("ReadMapEnd: %v", err)
+	}
+}
+
+func TestCompactProtocolEmptyMapIsSingleByte(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	if err := w.WriteMapBegin(types.STRING, types.I32, 0); err != nil {
+		t.Fatalf("WriteMapBegin: %v", err)
+	}
+	if buf.Len() != 1 {
+		t.Fatalf("encoded length = %d, want 1", buf.Len())
+	}
+
+	r := NewCompactProtocol(&buf)
+	keyType, valueType, size, err := r.ReadMapBegin()
+	if err != nil {
+		t.Fatalf("ReadMapBegin: %v", err)
+	}
+	if keyType != types.STOP || valueType != types.STOP || size != 0 {
+		t.Fatalf("got (key=%v, value=%v, size=%d), want (STOP, STOP, 0)", keyType, valueType, size)
+	}
+}
+
+func TestCompactProtocolReadBinaryNegativeSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCompactProtocol(&buf)
+	if err := w.writeVarint32(-1); err != nil {
+		t.Fatalf("writeVarint32: %v", err)
+	}
+
+	r := NewCompactProtocol(&buf)
+	if _, err := r.ReadBinary(); err == nil {
+		t.Fatal("ReadBinary accepted a negative size")
+	}
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package format
+
+import (
+	"fmt"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+// NewFormat returns the types.Format that reads and writes the given
+// protocol on top of rw.
+func NewFormat(protocolID types.ProtocolID, rw types.ReadWriteSizer) (types.Format, error) {
+	switch protocolID {
+	case types.ProtocolIDCompact:
+		return NewCompactProtocol(rw), nil
+	default:
+		return nil, fmt.Errorf("format: unsupported protocol id %v", protocolID)
+	}
+}
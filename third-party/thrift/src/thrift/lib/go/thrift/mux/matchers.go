@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"bytes"
+	"io"
+)
+
+// http2Preface is the fixed client connection preface that opens every
+// HTTP/2 connection, RFC 7540 section 3.5.
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// thriftBinaryMagic is the high two bytes of a Thrift Binary protocol
+// message's version/type header, VERSION_1 (0x80010000) as written by
+// WriteMessageBegin.
+var thriftBinaryMagic = []byte{0x80, 0x01}
+
+// thriftHeaderMagic is the fixed 2-byte magic that opens every Thrift
+// Header protocol frame, following the frame's 4-byte length prefix.
+var thriftHeaderMagic = []byte{0x0f, 0xff}
+
+// httpMethods are the request-line tokens recognized by HTTP1Fast; "fast"
+// because it only checks the method token rather than parsing a full
+// request line.
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("HEAD "), []byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "),
+	[]byte("TRACE "),
+}
+
+func readAll(r io.Reader) []byte {
+	b, _ := io.ReadAll(r)
+	return b
+}
+
+// ThriftBinary matches a Thrift Binary protocol message: a 4-byte header
+// whose top two bytes are the strict-write version marker 0x8001.
+func ThriftBinary() Matcher {
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		return len(b) >= 4 && bytes.HasPrefix(b, thriftBinaryMagic)
+	}
+}
+
+// ThriftCompact matches a Thrift Compact protocol message: its first byte
+// is the fixed protocol id 0x82.
+func ThriftCompact() Matcher {
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		return len(b) >= 1 && b[0] == 0x82
+	}
+}
+
+// ThriftHeader matches a Thrift Header protocol frame: a 4-byte big-endian
+// length prefix followed by the 2-byte magic 0x0FFF.
+func ThriftHeader() Matcher {
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		return len(b) >= 6 && bytes.Equal(b[4:6], thriftHeaderMagic)
+	}
+}
+
+// HTTP1Fast matches the start of an HTTP/1.x request line by its method
+// token, without parsing the rest of the request.
+func HTTP1Fast() Matcher {
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		for _, m := range httpMethods {
+			if bytes.HasPrefix(b, m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HTTP2 matches the fixed HTTP/2 client connection preface. It reports a
+// match for any non-empty sniffed data that is a prefix of the preface
+// (or the whole preface), so once mux has sniffed len(http2Preface) bytes
+// this is a full-preface match; on a shorter sniffed prefix it matches as
+// soon as the bytes seen so far agree with the preface, and mux dispatches
+// on that first match. mux re-runs matchers against a growing, possibly
+// still empty prefix as more of the connection arrives, so this must not
+// treat an empty read as a match (bytes.HasPrefix(s, nil) is always true).
+func HTTP2() Matcher {
+	preface := []byte(http2Preface)
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		if len(b) == 0 {
+			return false
+		}
+		if len(b) > len(preface) {
+			b = b[:len(preface)]
+		}
+		return bytes.HasPrefix(preface, b)
+	}
+}
+
+// TLS matches the start of a TLS ClientHello record: content type 0x16
+// (handshake) followed by a 0x03 major version byte (TLS 1.0 through 1.3
+// all negotiate with 0x03 here for middlebox compatibility).
+func TLS() Matcher {
+	return func(r io.Reader) bool {
+		b := readAll(r)
+		return len(b) >= 2 && b[0] == 0x16 && b[1] == 0x03
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	cases := []struct {
+		name    string
+		matcher Matcher
+		prefix  []byte
+		want    bool
+	}{
+		{"ThriftBinary/match", ThriftBinary(), []byte{0x80, 0x01, 0x00, 0x01}, true},
+		{"ThriftBinary/wrong version", ThriftBinary(), []byte{0x80, 0x02, 0x00, 0x01}, false},
+		{"ThriftBinary/too short", ThriftBinary(), []byte{0x80}, false},
+
+		{"ThriftCompact/match", ThriftCompact(), []byte{0x82, 0x21, 0x00}, true},
+		{"ThriftCompact/wrong id", ThriftCompact(), []byte{0x80, 0x01}, false},
+		{"ThriftCompact/empty", ThriftCompact(), nil, false},
+
+		{"ThriftHeader/match", ThriftHeader(), []byte{0x00, 0x00, 0x00, 0x10, 0x0f, 0xff}, true},
+		{"ThriftHeader/too short", ThriftHeader(), []byte{0x00, 0x00, 0x00, 0x10, 0x0f}, false},
+		{"ThriftHeader/wrong magic", ThriftHeader(), []byte{0x00, 0x00, 0x00, 0x10, 0x00, 0x00}, false},
+
+		{"HTTP1Fast/GET", HTTP1Fast(), []byte("GET / HTTP/1.1\r\n"), true},
+		{"HTTP1Fast/POST", HTTP1Fast(), []byte("POST / HTTP/1.1\r\n"), true},
+		{"HTTP1Fast/no match", HTTP1Fast(), []byte("GARBAGE"), false},
+
+		{"HTTP2/full preface", HTTP2(), []byte(http2Preface), true},
+		{"HTTP2/partial preface prefix", HTTP2(), []byte(http2Preface)[:6], true},
+		{"HTTP2/empty does not match", HTTP2(), nil, false},
+		{"HTTP2/wrong bytes", HTTP2(), []byte("PRI * HTTP/1.1\r\n"), false},
+
+		{"TLS/match", TLS(), []byte{0x16, 0x03, 0x01, 0x00, 0x05}, true},
+		{"TLS/wrong content type", TLS(), []byte{0x17, 0x03, 0x01}, false},
+		{"TLS/too short", TLS(), []byte{0x16}, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.matcher(bytes.NewReader(tc.prefix))
+			if got != tc.want {
+				t.Fatalf("matcher(%q) = %v, want %v", tc.prefix, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTP2MatcherDoesNotMatchEmptyPrefix(t *testing.T) {
+	// Regression test: bytes.HasPrefix(preface, b) is true for any b of
+	// length 0, so without an explicit empty check every freshly sniffed
+	// connection would incorrectly match HTTP2 before any bytes arrive.
+	if HTTP2()(bytes.NewReader(nil)) {
+		t.Fatal("HTTP2 matched an empty prefix")
+	}
+}
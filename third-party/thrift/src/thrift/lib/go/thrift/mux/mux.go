@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package mux multiplexes protocols sharing a single net.Listener, the way
+// cmux does for gRPC/HTTP. It lets a service expose Thrift alongside a
+// health or metrics HTTP endpoint on one port, without a separate reverse
+// proxy in front of it, by sniffing a short prefix of each new connection
+// and routing it to the net.Listener registered for the matching protocol.
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// maxSniffLen is long enough to disambiguate every Matcher below; the
+// longest prefix any of them needs is the HTTP/2 connection preface.
+const maxSniffLen = len(http2Preface)
+
+// Matcher reports whether a connection's first bytes, available to read
+// from r, belong to the protocol it recognizes. r yields only the sniffed
+// prefix accumulated so far (up to maxSniffLen bytes), not the rest of the
+// connection; it may be called more than once per connection, with a
+// longer prefix each time, as more of the handshake arrives.
+type Matcher func(r io.Reader) bool
+
+// Mux dispatches incoming connections from a net.Listener across the
+// net.Listeners returned by Match, based on a sniffed prefix of each
+// connection. The zero value is not usable; construct with New.
+type Mux struct {
+	ln       net.Listener
+	matchers []*matchListener
+
+	donec     chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	finalErr error
+}
+
+// New wraps ln for protocol multiplexing. ln is no longer usable directly
+// once Serve is called; Accept connections through the listeners returned
+// by Match instead.
+func New(ln net.Listener) *Mux {
+	return &Mux{
+		ln:    ln,
+		donec: make(chan struct{}),
+	}
+}
+
+// Match registers a net.Listener for connections whose sniffed prefix
+// satisfies any of matchers, tried in order. Match must be called before
+// Serve.
+func (m *Mux) Match(matchers ...Matcher) net.Listener {
+	ml := &matchListener{
+		mux:      m,
+		matchers: matchers,
+		connc:    make(chan net.Conn),
+		closedc:  make(chan struct{}),
+	}
+	m.matchers = append(m.matchers, ml)
+	return ml
+}
+
+// ErrNotMatched is returned by a sub-listener's Accept once Mux's
+// underlying listener has stopped serving for a reason other than an
+// explicit error (which is not expected to happen in practice, since
+// Mux.Serve always has a concrete Accept error to report, but is handled
+// defensively).
+var ErrNotMatched = errors.New("mux: underlying listener stopped")
+
+// ErrListenerClosed is returned by a sub-listener's Accept after its own
+// Close has been called, independent of the other sub-listeners or of the
+// shared underlying listener.
+var ErrListenerClosed = errors.New("mux: listener closed")
+
+// Serve accepts connections from the wrapped listener, sniffs each one
+// against every registered Matcher in registration order, and hands it to
+// the first matching listener's Accept. Connections matching nothing are
+// closed. Serve blocks until the underlying listener's Accept returns an
+// error (including after Close), which it then returns.
+func (m *Mux) Serve() error {
+	for {
+		conn, err := m.ln.Accept()
+		if err != nil {
+			m.closeAll(err)
+			return err
+		}
+		go m.serve(conn)
+	}
+}
+
+// Close closes the shared underlying listener, which stops Serve and
+// causes every sub-listener's Accept to start returning an error. It does
+// not affect a sub-listener that was already independently closed via its
+// own Close.
+func (m *Mux) Close() error {
+	return m.ln.Close()
+}
+
+// serve sniffs conn against every registered matcher, reading more of the
+// connection incrementally (up to maxSniffLen bytes total) between
+// attempts so a handshake split across multiple TCP reads — e.g. a Thrift
+// Header frame's length prefix and magic written in separate calls — still
+// gets matched instead of being judged on whatever partial prefix the
+// first read happened to return.
+func (m *Mux) serve(conn net.Conn) {
+	buf := make([]byte, 0, maxSniffLen)
+	tmp := make([]byte, maxSniffLen)
+	for {
+		if ml := m.tryMatch(buf); ml != nil {
+			m.dispatch(ml, conn, buf)
+			return
+		}
+		if len(buf) >= maxSniffLen {
+			break
+		}
+		n, err := conn.Read(tmp[:maxSniffLen-len(buf)])
+		if n > 0 {
+			buf = append(buf, tmp[:n]...)
+		}
+		if err != nil {
+			if ml := m.tryMatch(buf); ml != nil {
+				m.dispatch(ml, conn, buf)
+				return
+			}
+			break
+		}
+	}
+	conn.Close()
+}
+
+func (m *Mux) tryMatch(buf []byte) *matchListener {
+	for _, ml := range m.matchers {
+		for _, matcher := range ml.matchers {
+			if matcher(bytes.NewReader(buf)) {
+				return ml
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Mux) dispatch(ml *matchListener, conn net.Conn, sniffed []byte) {
+	wrapped := &sniffedConn{Conn: conn, prefix: append([]byte(nil), sniffed...)}
+	select {
+	case ml.connc <- wrapped:
+	case <-ml.closedc:
+		conn.Close()
+	case <-m.donec:
+		conn.Close()
+	}
+}
+
+// closeAll records the terminal error and signals every sub-listener's
+// Accept (via donec) that the underlying listener has stopped. It
+// deliberately does not close any matchListener's connc: a dispatch
+// goroutine can be concurrently parked trying to send a freshly sniffed
+// connection on it (select { case ml.connc <- wrapped: ...; case
+// <-m.donec: ... }), and closing a channel a sender is blocked on panics
+// regardless of the other select case. donec being closed is enough for
+// both that select and Accept to unblock.
+func (m *Mux) closeAll(err error) {
+	m.closeOnce.Do(func() {
+		m.mu.Lock()
+		m.finalErr = err
+		m.mu.Unlock()
+		close(m.donec)
+	})
+}
+
+func (m *Mux) terminalError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.finalErr != nil {
+		return m.finalErr
+	}
+	return ErrNotMatched
+}
+
+// sniffedConn re-prepends the bytes consumed while sniffing so the
+// protocol-specific listener's consumer sees the connection exactly as if
+// it had read it without any multiplexing in front.
+type sniffedConn struct {
+	net.Conn
+	prefix []byte
+	read   int
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	if c.read < len(c.prefix) {
+		n := copy(p, c.prefix[c.read:])
+		c.read += n
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+// matchListener is the net.Listener returned by Mux.Match. Closing it only
+// stops routing matched connections to it — it does not touch the shared
+// underlying listener or any other sub-listener, so (for example) an HTTP
+// health-check sub-listener shutting down doesn't take the Thrift
+// sub-listener sharing the port down with it.
+type matchListener struct {
+	mux      *Mux
+	matchers []Matcher
+	connc    chan net.Conn
+
+	closeOnce sync.Once
+	closedc   chan struct{}
+}
+
+func (ml *matchListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-ml.connc:
+		return conn, nil
+	case <-ml.closedc:
+		return nil, ErrListenerClosed
+	case <-ml.mux.donec:
+		return nil, ml.mux.terminalError()
+	}
+}
+
+func (ml *matchListener) Close() error {
+	ml.closeOnce.Do(func() {
+		close(ml.closedc)
+	})
+	return nil
+}
+
+func (ml *matchListener) Addr() net.Addr {
+	return ml.mux.ln.Addr()
+}
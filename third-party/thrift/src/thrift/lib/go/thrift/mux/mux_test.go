@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mux
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeListener is a net.Listener backed by an in-memory channel of
+// net.Conns, so tests can hand Mux connections produced by net.Pipe
+// without binding a real socket.
+type fakeListener struct {
+	connc chan net.Conn
+
+	closeOnce sync.Once
+	closedc   chan struct{}
+}
+
+func newFakeListener() *fakeListener {
+	return &fakeListener{connc: make(chan net.Conn), closedc: make(chan struct{})}
+}
+
+func (l *fakeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connc:
+		return conn, nil
+	case <-l.closedc:
+		return nil, errors.New("fakeListener: closed")
+	}
+}
+
+func (l *fakeListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closedc) })
+	return nil
+}
+
+func (l *fakeListener) Addr() net.Addr { return fakeAddr{} }
+
+type fakeAddr struct{}
+
+func (fakeAddr) Network() string { return "fake" }
+func (fakeAddr) String() string  { return "fake" }
+
+func TestMuxDispatchesBySniffedPrefix(t *testing.T) {
+	ln := newFakeListener()
+	m := New(ln)
+	compactLn := m.Match(ThriftCompact())
+	httpLn := m.Match(HTTP1Fast())
+
+	go m.Serve()
+	defer m.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	ln.connc <- serverConn
+
+	// Split across two writes, the way a real TCP connection's handshake
+	// bytes can arrive in separate reads.
+	go func() {
+		clientConn.Write([]byte{0x82})
+		clientConn.Write([]byte{0x21, 0x00})
+	}()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := compactLn.Accept()
+		if err != nil {
+			t.Errorf("compactLn.Accept: %v", err)
+			return
+		}
+		accepted <- conn
+	}()
+
+	select {
+	case conn := <-accepted:
+		buf := make([]byte, 3)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatalf("reading sniffed prefix back: %v", err)
+		}
+		if buf[0] != 0x82 {
+			t.Fatalf("sniffed prefix not replayed to consumer, got %v", buf)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for compact listener to accept")
+	}
+
+	// The HTTP listener must not have received anything.
+	select {
+	case conn, ok := <-httpLn.(*matchListener).connc:
+		t.Fatalf("unexpected connection delivered to http listener: %v %v", conn, ok)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMuxSplitHandshakeAcrossMultipleReads(t *testing.T) {
+	ln := newFakeListener()
+	m := New(ln)
+	headerLn := m.Match(ThriftHeader())
+
+	go m.Serve()
+	defer m.Close()
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	ln.connc <- serverConn
+
+	// ThriftHeader needs 6 bytes (4-byte length prefix + 2-byte magic),
+	// delivered here across three separate writes so a sniffer that only
+	// looks at the very first read would never match.
+	frame := []byte{0x00, 0x00, 0x00, 0x10, 0x0f, 0xff}
+	go func() {
+		clientConn.Write(frame[:2])
+		time.Sleep(10 * time.Millisecond)
+		clientConn.Write(frame[2:4])
+		time.Sleep(10 * time.Millisecond)
+		clientConn.Write(frame[4:])
+	}()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := headerLn.Accept()
+		if err != nil {
+			t.Errorf("headerLn.Accept: %v", err)
+			return
+		}
+		accepted <- conn
+	}()
+
+	select {
+	case <-accepted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for split handshake to be matched")
+	}
+}
+
+func TestMatchListenerCloseIsIndependent(t *testing.T) {
+	ln := newFakeListener()
+	m := New(ln)
+	a := m.Match(ThriftCompact())
+	b := m.Match(HTTP1Fast())
+	go m.Serve()
+	defer m.Close()
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("a.Close: %v", err)
+	}
+	if _, err := a.Accept(); err != ErrListenerClosed {
+		t.Fatalf("a.Accept after Close = %v, want ErrListenerClosed", err)
+	}
+
+	// b must still be unaffected by a's Close.
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	ln.connc <- serverConn
+	go clientConn.Write([]byte("GET / HTTP/1.1\r\n"))
+
+	done := make(chan struct{})
+	go func() {
+		if _, err := b.Accept(); err != nil {
+			t.Errorf("b.Accept: %v", err)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out: closing listener a affected listener b")
+	}
+}
+
+func TestMuxCloseUnblocksAllAcceptsRepeatedly(t *testing.T) {
+	ln := newFakeListener()
+	m := New(ln)
+	a := m.Match(ThriftCompact())
+	b := m.Match(HTTP1Fast())
+	go m.Serve()
+
+	ln.Close() // causes Serve's Accept to return an error, triggering closeAll
+	select {
+	case <-m.donec:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for mux shutdown to propagate")
+	}
+
+	for _, sub := range []net.Listener{a, b} {
+		for i := 0; i < 3; i++ {
+			if _, err := sub.Accept(); err == nil {
+				t.Fatalf("Accept call %d on a closed mux returned no error", i)
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tracing provides a types.Interceptor that records a client span
+// per Thrift call and propagates the active trace context (W3C traceparent
+// or Zipkin B3) to the peer. It is deliberately independent of any
+// particular tracing SDK: callers adapt their OpenTelemetry/OpenTracing
+// tracer to the small Tracer interface below.
+package tracing
+
+import (
+	"context"
+
+	"github.com/facebook/fbthrift/thrift/lib/go/thrift/types"
+)
+
+// Span is the subset of a tracing SDK's span needed to record a Thrift
+// call: the method name as the span's operation, an error if the call
+// failed, and the header value to propagate to the peer.
+type Span interface {
+	// SetTag attaches a key/value pair to the span, e.g. "thrift.method"
+	// or "thrift.protocol".
+	SetTag(key string, value any)
+
+	// SetError marks the span as failed.
+	SetError(err error)
+
+	// TraceParent returns the propagation header (W3C traceparent or
+	// Zipkin B3) for the span's current context, to be written into the
+	// outgoing Thrift header frame.
+	TraceParent() string
+
+	// Finish ends the span.
+	Finish()
+}
+
+// Tracer starts a child span for an outgoing Thrift call. Implementations
+// typically wrap an OpenTelemetry Tracer or OpenTracing Tracer, reading any
+// parent span already present on ctx.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+// traceParentHeader is the Thrift header field name under which the
+// propagation header is sent, matching the W3C Trace Context header name so
+// HTTP/Thrift bridges don't need to translate it.
+const traceParentHeader = "traceparent"
+
+// Interceptor returns a types.Interceptor that starts a client span named
+// after the call's method for every SendRequestResponse/
+// SendRequestNoResponse and records the call's outcome. It propagates the
+// span to the peer via a Thrift header only when next implements
+// headerSetter, and records the underlying channel's protocol/format as a
+// tag only when next implements formatDescriber.
+func Interceptor(tracer Tracer) types.Interceptor {
+	return func(next types.RequestChannel) types.RequestChannel {
+		return &tracingChannel{next: next, tracer: tracer}
+	}
+}
+
+type tracingChannel struct {
+	next   types.RequestChannel
+	tracer Tracer
+}
+
+func (c *tracingChannel) Close() error {
+	return c.next.Close()
+}
+
+func (c *tracingChannel) SendRequestResponse(ctx context.Context, method string, request types.WritableStruct, response types.ReadableStruct) error {
+	ctx, span := c.startSpan(ctx, method)
+	defer span.Finish()
+	err := c.next.SendRequestResponse(ctx, method, request, response)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+func (c *tracingChannel) SendRequestNoResponse(ctx context.Context, method string, request types.WritableStruct) error {
+	ctx, span := c.startSpan(ctx, method)
+	defer span.Finish()
+	err := c.next.SendRequestNoResponse(ctx, method, request)
+	if err != nil {
+		span.SetError(err)
+	}
+	return err
+}
+
+func (c *tracingChannel) startSpan(ctx context.Context, method string) (context.Context, Span) {
+	ctx, span := c.tracer.StartSpan(ctx, method)
+	span.SetTag("thrift.method", method)
+	if fd, ok := c.next.(formatDescriber); ok {
+		span.SetTag("thrift.protocol", fd.Format().String())
+	}
+	if headerCh, ok := c.next.(headerSetter); ok {
+		headerCh.SetHeader(traceParentHeader, span.TraceParent())
+	}
+	return ctx, span
+}
+
+// headerSetter is implemented by RequestChannels that can attach a per-call
+// Thrift header; it's satisfied structurally so this package doesn't need
+// to depend on a concrete header-channel implementation.
+type headerSetter interface {
+	SetHeader(key, value string)
+}
+
+// formatDescriber is implemented by RequestChannels that can report which
+// wire protocol/format they're using. RequestChannel itself hides this
+// (it's meant to abstract over the protocol entirely), so it's only
+// recorded when the concrete channel opts in structurally like this.
+type formatDescriber interface {
+	Format() types.ProtocolID
+}
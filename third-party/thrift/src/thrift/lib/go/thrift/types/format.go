@@ -193,11 +193,8 @@ func Skip(self Decoder, fieldType Type, maxDepth int) error {
 		if err != nil {
 			return err
 		}
-		for i := 0; i < size; i++ {
-			err := Skip(self, elemType, maxDepth-1)
-			if err != nil {
-				return err
-			}
+		if err := skipElements(self, elemType, size, maxDepth); err != nil {
+			return err
 		}
 		return self.ReadSetEnd()
 	case LIST:
@@ -205,11 +202,8 @@ func Skip(self Decoder, fieldType Type, maxDepth int) error {
 		if err != nil {
 			return err
 		}
-		for i := 0; i < size; i++ {
-			err := Skip(self, elemType, maxDepth-1)
-			if err != nil {
-				return err
-			}
+		if err := skipElements(self, elemType, size, maxDepth); err != nil {
+			return err
 		}
 		return self.ReadListEnd()
 	default:
@@ -217,6 +211,57 @@ func Skip(self Decoder, fieldType Type, maxDepth int) error {
 	}
 }
 
+// skipElements skips size elements of elemType from a LIST or SET. When
+// self is a FastSkipper and elemType is a fixed-width scalar, it skips the
+// whole run in one call instead of one Skip (and one virtual read call)
+// per element.
+func skipElements(self Decoder, elemType Type, size int, maxDepth int) error {
+	if _, ok := fixedWidth(elemType); ok {
+		if fs, ok := self.(FastSkipper); ok {
+			return fs.SkipList(elemType, size)
+		}
+	}
+	for i := 0; i < size; i++ {
+		if err := Skip(self, elemType, maxDepth-1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixedWidth reports the on-the-wire byte width of a scalar type for
+// formats that encode it as a fixed number of bytes (true for Binary;
+// Compact instead varint-encodes I16/I32/I64, so its FastSkipper batches
+// those with a varint scan rather than a fixed stride).
+func fixedWidth(t Type) (width int, ok bool) {
+	switch t {
+	case BOOL, BYTE:
+		return 1, true
+	case I16:
+		return 2, true
+	case I32, FLOAT:
+		return 4, true
+	case I64, DOUBLE:
+		return 8, true
+	default:
+		return 0, false
+	}
+}
+
+// FastSkipper is an optional capability of a Decoder that lets Skip advance
+// past a run of fixed-width scalar elements (or a raw byte run) without a
+// virtual call per element, which otherwise dominates the cost of skipping
+// unknown fields in large payloads. Concrete formats implement it by
+// advancing their underlying ReadSizer directly; formats that don't
+// implement FastSkipper fall back to Skip's ordinary per-element loop.
+type FastSkipper interface {
+	// SkipList skips size consecutive elements of the given fixed-width
+	// scalar elemType, as found in a LIST or SET of primitives.
+	SkipList(elemType Type, size int) error
+	// SkipBytes skips n raw bytes, as found in a STRING/BINARY value.
+	SkipBytes(n int) error
+}
+
 // Flusher is the interface that wraps the basic Flush method
 type Flusher interface {
 	Flush() (err error)
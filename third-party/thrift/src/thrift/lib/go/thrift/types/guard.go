@@ -0,0 +1,361 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"errors"
+)
+
+// GuardLimits bounds how much a GuardedDecoder will trust a peer's claims
+// about a message before giving up, defending against a well-known Thrift
+// attack surface: a hostile peer declares a huge container size or string
+// length and forces the caller to preallocate before the decoder ever
+// reports an error.
+type GuardLimits struct {
+	// MaxContainerSize bounds the declared size of any single LIST, SET,
+	// or MAP. Zero means no limit.
+	MaxContainerSize int
+
+	// MaxStringLength bounds the declared length of any single STRING or
+	// BINARY value. Zero means no limit.
+	MaxStringLength int
+
+	// MaxBytes bounds the cumulative number of wire bytes a single
+	// decode may consume. Zero means no limit.
+	MaxBytes int64
+
+	// MaxDepth bounds struct/container nesting depth, independent of
+	// DEFAULT_RECURSION_DEPTH (which only governs Skip). Zero means no
+	// limit.
+	MaxDepth int
+}
+
+// lenReporter is implemented by formats built on a ReadSizer; when the
+// wrapped Decoder satisfies it, GuardedDecoder counts bytes consumed by
+// diffing Len() around each call instead of summing returned value sizes.
+type lenReporter interface {
+	Len() int
+}
+
+// maxStringLengthSetter is implemented by formats that can reject an
+// oversized STRING/BINARY length themselves, before allocating a buffer
+// for it. GuardedDecoder pushes MaxStringLength down to the wrapped
+// Decoder through this interface at construction time so the limit is
+// enforced where the allocation actually happens; formats that don't
+// implement it only get the length checked after the fact, once the
+// allocation has already occurred.
+type maxStringLengthSetter interface {
+	SetMaxStringLength(n int)
+}
+
+// NewGuardedDecoder wraps inner so that every read is checked against
+// limits before the caller sees a container size or string length, and
+// returns a typed exception the moment a limit is exceeded.
+func NewGuardedDecoder(inner Decoder, limits GuardLimits) Decoder {
+	g := &guardedDecoder{inner: inner, limits: limits}
+	if lr, ok := inner.(lenReporter); ok {
+		g.lenReporter = lr
+		g.lastLen = lr.Len()
+	}
+	if limits.MaxStringLength > 0 {
+		if s, ok := inner.(maxStringLengthSetter); ok {
+			s.SetMaxStringLength(limits.MaxStringLength)
+		}
+	}
+	return g
+}
+
+type guardedDecoder struct {
+	inner  Decoder
+	limits GuardLimits
+
+	lenReporter lenReporter
+	lastLen     int
+	bytesRead   int64
+
+	depth int
+}
+
+func (g *guardedDecoder) exceeded(exceptionType int, msg string) error {
+	return NewProtocolExceptionWithType(exceptionType, errors.New(msg))
+}
+
+// accountBytes folds in whatever the most recent call consumed from the
+// underlying ReadSizer, and fails once the cumulative total crosses
+// MaxBytes. It's a no-op when the wrapped Decoder isn't a lenReporter.
+func (g *guardedDecoder) accountBytes() error {
+	if g.lenReporter == nil || g.limits.MaxBytes == 0 {
+		return nil
+	}
+	n := g.lenReporter.Len()
+	g.bytesRead += int64(g.lastLen - n)
+	g.lastLen = n
+	if g.bytesRead > g.limits.MaxBytes {
+		return g.exceeded(SIZE_LIMIT, "types: MaxBytes limit exceeded")
+	}
+	return nil
+}
+
+func (g *guardedDecoder) enterDepth() error {
+	g.depth++
+	if g.limits.MaxDepth > 0 && g.depth > g.limits.MaxDepth {
+		return g.exceeded(DEPTH_LIMIT, "types: MaxDepth limit exceeded")
+	}
+	return nil
+}
+
+func (g *guardedDecoder) leaveDepth() {
+	g.depth--
+}
+
+func (g *guardedDecoder) checkContainerSize(size int) error {
+	if g.limits.MaxContainerSize > 0 && size > g.limits.MaxContainerSize {
+		return g.exceeded(SIZE_LIMIT, "types: MaxContainerSize limit exceeded")
+	}
+	return nil
+}
+
+// ReadMessageBegin resets the per-message counters: MaxBytes and MaxDepth
+// bound a single decode (one message), not the lifetime of a Decoder
+// that's reused across a connection's message loop. This also discards any
+// depth left over from a message whose decode aborted mid-struct, where
+// the matching ReadStructEnd/ReadListEnd/etc. that would otherwise balance
+// enterDepth/leaveDepth never ran.
+func (g *guardedDecoder) ReadMessageBegin() (name string, typeID MessageType, seqid int32, err error) {
+	g.depth = 0
+	g.bytesRead = 0
+	if g.lenReporter != nil {
+		g.lastLen = g.lenReporter.Len()
+	}
+	name, typeID, seqid, err = g.inner.ReadMessageBegin()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return name, typeID, seqid, err
+}
+
+func (g *guardedDecoder) ReadMessageEnd() error {
+	return g.inner.ReadMessageEnd()
+}
+
+func (g *guardedDecoder) ReadStructBegin() (name string, err error) {
+	if err := g.enterDepth(); err != nil {
+		return "", err
+	}
+	name, err = g.inner.ReadStructBegin()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return name, err
+}
+
+func (g *guardedDecoder) ReadStructEnd() error {
+	g.leaveDepth()
+	return g.inner.ReadStructEnd()
+}
+
+func (g *guardedDecoder) ReadFieldBegin() (name string, typeID Type, id int16, err error) {
+	name, typeID, id, err = g.inner.ReadFieldBegin()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return name, typeID, id, err
+}
+
+func (g *guardedDecoder) ReadFieldEnd() error {
+	return g.inner.ReadFieldEnd()
+}
+
+func (g *guardedDecoder) ReadMapBegin() (keyType Type, valueType Type, size int, err error) {
+	if err := g.enterDepth(); err != nil {
+		return 0, 0, 0, err
+	}
+	keyType, valueType, size, err = g.inner.ReadMapBegin()
+	if err != nil {
+		return keyType, valueType, size, err
+	}
+	if err := g.checkContainerSize(size); err != nil {
+		return keyType, valueType, size, err
+	}
+	return keyType, valueType, size, g.accountBytes()
+}
+
+func (g *guardedDecoder) ReadMapEnd() error {
+	g.leaveDepth()
+	return g.inner.ReadMapEnd()
+}
+
+func (g *guardedDecoder) ReadListBegin() (elemType Type, size int, err error) {
+	if err := g.enterDepth(); err != nil {
+		return 0, 0, err
+	}
+	elemType, size, err = g.inner.ReadListBegin()
+	if err != nil {
+		return elemType, size, err
+	}
+	if err := g.checkContainerSize(size); err != nil {
+		return elemType, size, err
+	}
+	return elemType, size, g.accountBytes()
+}
+
+func (g *guardedDecoder) ReadListEnd() error {
+	g.leaveDepth()
+	return g.inner.ReadListEnd()
+}
+
+func (g *guardedDecoder) ReadSetBegin() (elemType Type, size int, err error) {
+	if err := g.enterDepth(); err != nil {
+		return 0, 0, err
+	}
+	elemType, size, err = g.inner.ReadSetBegin()
+	if err != nil {
+		return elemType, size, err
+	}
+	if err := g.checkContainerSize(size); err != nil {
+		return elemType, size, err
+	}
+	return elemType, size, g.accountBytes()
+}
+
+func (g *guardedDecoder) ReadSetEnd() error {
+	g.leaveDepth()
+	return g.inner.ReadSetEnd()
+}
+
+func (g *guardedDecoder) ReadBool() (value bool, err error) {
+	value, err = g.inner.ReadBool()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadByte() (value byte, err error) {
+	value, err = g.inner.ReadByte()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadI16() (value int16, err error) {
+	value, err = g.inner.ReadI16()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadI32() (value int32, err error) {
+	value, err = g.inner.ReadI32()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadI64() (value int64, err error) {
+	value, err = g.inner.ReadI64()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadDouble() (value float64, err error) {
+	value, err = g.inner.ReadDouble()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+func (g *guardedDecoder) ReadFloat() (value float32, err error) {
+	value, err = g.inner.ReadFloat()
+	if err == nil {
+		err = g.accountBytes()
+	}
+	return value, err
+}
+
+// ReadString relies on NewGuardedDecoder having already pushed
+// MaxStringLength into inner via maxStringLengthSetter, so a format that
+// implements it (e.g. format.CompactProtocol) rejects an oversized length
+// itself before allocating. checkStringLength below only catches formats
+// that don't implement the setter, and by then the allocation has already
+// happened — it's a backstop, not the primary defense.
+func (g *guardedDecoder) ReadString() (value string, err error) {
+	value, err = g.inner.ReadString()
+	if err != nil {
+		return value, err
+	}
+	if err := g.checkStringLength(len(value)); err != nil {
+		return value, err
+	}
+	return value, g.accountBytesFallback(len(value))
+}
+
+// ReadBinary: see ReadString.
+func (g *guardedDecoder) ReadBinary() (value []byte, err error) {
+	value, err = g.inner.ReadBinary()
+	if err != nil {
+		return value, err
+	}
+	if err := g.checkStringLength(len(value)); err != nil {
+		return value, err
+	}
+	return value, g.accountBytesFallback(len(value))
+}
+
+func (g *guardedDecoder) checkStringLength(n int) error {
+	if g.limits.MaxStringLength > 0 && n > g.limits.MaxStringLength {
+		return g.exceeded(SIZE_LIMIT, "types: MaxStringLength limit exceeded")
+	}
+	return nil
+}
+
+// accountBytesFallback folds in n bytes directly when the wrapped Decoder
+// isn't a lenReporter (accountBytes would otherwise be a no-op for it).
+func (g *guardedDecoder) accountBytesFallback(n int) error {
+	if g.lenReporter != nil {
+		return g.accountBytes()
+	}
+	if g.limits.MaxBytes == 0 {
+		return nil
+	}
+	g.bytesRead += int64(n)
+	if g.bytesRead > g.limits.MaxBytes {
+		return g.exceeded(SIZE_LIMIT, "types: MaxBytes limit exceeded")
+	}
+	return nil
+}
+
+// Skip reuses the package's generic Skip algorithm with g as the Decoder,
+// so every ReadXBegin it triggers is re-checked against limits the same as
+// a caller decoding the struct directly would be. Its own recursion is
+// bounded by MaxDepth when that is set, and by DEFAULT_RECURSION_DEPTH
+// when MaxDepth is zero.
+func (g *guardedDecoder) Skip(fieldType Type) error {
+	maxDepth := g.limits.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DEFAULT_RECURSION_DEPTH
+	}
+	return Skip(g, fieldType, maxDepth)
+}
+
+var _ Decoder = (*guardedDecoder)(nil)
@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import "testing"
+
+// stubDecoder is a minimal, scriptable Decoder for exercising
+// guardedDecoder in isolation, without depending on a concrete wire
+// format. Each Read*Fn is optional; unset ones return a zero value and no
+// error.
+type stubDecoder struct {
+	readStructBeginFn func() (string, error)
+	readListBeginFn   func() (Type, int, error)
+	readSetBeginFn    func() (Type, int, error)
+	readMapBeginFn    func() (Type, Type, int, error)
+	readStringFn      func() (string, error)
+	readBinaryFn      func() ([]byte, error)
+}
+
+func (s *stubDecoder) ReadMessageBegin() (string, MessageType, int32, error) { return "", 0, 0, nil }
+func (s *stubDecoder) ReadMessageEnd() error                                 { return nil }
+
+func (s *stubDecoder) ReadStructBegin() (string, error) {
+	if s.readStructBeginFn != nil {
+		return s.readStructBeginFn()
+	}
+	return "", nil
+}
+func (s *stubDecoder) ReadStructEnd() error { return nil }
+
+func (s *stubDecoder) ReadFieldBegin() (string, Type, int16, error) { return "", STOP, 0, nil }
+func (s *stubDecoder) ReadFieldEnd() error                          { return nil }
+
+func (s *stubDecoder) ReadMapBegin() (Type, Type, int, error) {
+	if s.readMapBeginFn != nil {
+		return s.readMapBeginFn()
+	}
+	return 0, 0, 0, nil
+}
+func (s *stubDecoder) ReadMapEnd() error { return nil }
+
+func (s *stubDecoder) ReadListBegin() (Type, int, error) {
+	if s.readListBeginFn != nil {
+		return s.readListBeginFn()
+	}
+	return 0, 0, nil
+}
+func (s *stubDecoder) ReadListEnd() error { return nil }
+
+func (s *stubDecoder) ReadSetBegin() (Type, int, error) {
+	if s.readSetBeginFn != nil {
+		return s.readSetBeginFn()
+	}
+	return 0, 0, nil
+}
+func (s *stubDecoder) ReadSetEnd() error { return nil }
+
+func (s *stubDecoder) ReadBool() (bool, error)      { return false, nil }
+func (s *stubDecoder) ReadByte() (byte, error)      { return 0, nil }
+func (s *stubDecoder) ReadI16() (int16, error)      { return 0, nil }
+func (s *stubDecoder) ReadI32() (int32, error)      { return 0, nil }
+func (s *stubDecoder) ReadI64() (int64, error)      { return 0, nil }
+func (s *stubDecoder) ReadDouble() (float64, error) { return 0, nil }
+func (s *stubDecoder) ReadFloat() (float32, error)  { return 0, nil }
+
+func (s *stubDecoder) ReadString() (string, error) {
+	if s.readStringFn != nil {
+		return s.readStringFn()
+	}
+	return "", nil
+}
+
+func (s *stubDecoder) ReadBinary() ([]byte, error) {
+	if s.readBinaryFn != nil {
+		return s.readBinaryFn()
+	}
+	return nil, nil
+}
+
+func (s *stubDecoder) Skip(fieldType Type) error { return nil }
+
+var _ Decoder = (*stubDecoder)(nil)
+
+func TestGuardedDecoderRejectsOversizedContainer(t *testing.T) {
+	inner := &stubDecoder{
+		readListBeginFn: func() (Type, int, error) { return I32, 1000, nil },
+	}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxContainerSize: 10})
+
+	if _, _, err := g.ReadListBegin(); err == nil {
+		t.Fatal("ReadListBegin accepted a container size over MaxContainerSize")
+	}
+}
+
+func TestGuardedDecoderAllowsContainerWithinLimit(t *testing.T) {
+	inner := &stubDecoder{
+		readListBeginFn: func() (Type, int, error) { return I32, 5, nil },
+	}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxContainerSize: 10})
+
+	if _, _, err := g.ReadListBegin(); err != nil {
+		t.Fatalf("ReadListBegin rejected a container within MaxContainerSize: %v", err)
+	}
+}
+
+func TestGuardedDecoderRejectsOversizedString(t *testing.T) {
+	inner := &stubDecoder{
+		readStringFn: func() (string, error) { return "this string is far too long", nil },
+	}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxStringLength: 4})
+
+	if _, err := g.ReadString(); err == nil {
+		t.Fatal("ReadString accepted a string over MaxStringLength")
+	}
+}
+
+func TestGuardedDecoderRejectsOversizedBinary(t *testing.T) {
+	inner := &stubDecoder{
+		readBinaryFn: func() ([]byte, error) { return make([]byte, 100), nil },
+	}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxStringLength: 4})
+
+	if _, err := g.ReadBinary(); err == nil {
+		t.Fatal("ReadBinary accepted a binary value over MaxStringLength")
+	}
+}
+
+func TestGuardedDecoderRejectsExcessiveDepth(t *testing.T) {
+	inner := &stubDecoder{}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxDepth: 2})
+
+	if _, err := g.ReadStructBegin(); err != nil {
+		t.Fatalf("depth 1: unexpected error: %v", err)
+	}
+	if _, err := g.ReadStructBegin(); err != nil {
+		t.Fatalf("depth 2: unexpected error: %v", err)
+	}
+	if _, err := g.ReadStructBegin(); err == nil {
+		t.Fatal("depth 3 exceeded MaxDepth but ReadStructBegin returned no error")
+	}
+}
+
+func TestGuardedDecoderResetsDepthOnNewMessage(t *testing.T) {
+	inner := &stubDecoder{}
+	g := NewGuardedDecoder(inner, GuardLimits{MaxDepth: 1})
+
+	// Enter one level of depth and leave the decoder in that state, as if
+	// a prior message's decode aborted mid-struct without a matching
+	// ReadStructEnd.
+	if _, err := g.ReadStructBegin(); err != nil {
+		t.Fatalf("unexpected error entering depth: %v", err)
+	}
+
+	if _, _, _, err := g.ReadMessageBegin(); err != nil {
+		t.Fatalf("ReadMessageBegin: %v", err)
+	}
+
+	// If depth hadn't been reset, this would already be at MaxDepth and
+	// fail immediately.
+	if _, err := g.ReadStructBegin(); err != nil {
+		t.Fatalf("ReadStructBegin after ReadMessageBegin should not see leftover depth: %v", err)
+	}
+}
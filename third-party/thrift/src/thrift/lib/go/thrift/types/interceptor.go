@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// Interceptor wraps a RequestChannel with additional behavior (tracing,
+// metrics, retries, ...) without the wrapped channel or its caller needing
+// to know about it. Generated client code and callers that build a
+// RequestChannel directly are unaffected either way.
+type Interceptor func(next RequestChannel) RequestChannel
+
+// ChainInterceptors composes interceptors into a single Interceptor. The
+// first interceptor in the list is outermost: it sees a call before any
+// other interceptor and sees the final result last, e.g.
+// ChainInterceptors(a, b)(ch) behaves like a(b(ch)).
+func ChainInterceptors(interceptors ...Interceptor) Interceptor {
+	return func(next RequestChannel) RequestChannel {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			if interceptors[i] != nil {
+				next = interceptors[i](next)
+			}
+		}
+		return next
+	}
+}
@@ -35,6 +35,54 @@ type RequestChannel interface {
 	SendRequestNoResponse(ctx context.Context, method string, request WritableStruct) error
 }
 
+// StreamingRequestChannel is an optional capability of a RequestChannel
+// implementation that also supports Rocket-style server-push streams.
+// Generated client code should type-assert a RequestChannel to this
+// interface and fall back to SendRequestResponse when the assertion fails
+// or the peer didn't negotiate streaming support. FallbackStreamingChannel
+// adapts any RequestChannel to this interface, so the type assertion
+// always succeeds; a transport that supports streaming for real should
+// implement StreamingRequestChannel directly rather than relying on the
+// fallback, typically building its StreamHandle on CreditStreamHandle.
+type StreamingRequestChannel interface {
+	RequestChannel
+
+	// SendRequestStream issues a request that expects a server-driven
+	// stream of responses. onMessage is invoked synchronously for each
+	// message the peer pushes, in order; returning a non-nil error from
+	// onMessage cancels the stream. The returned StreamHandle stays live
+	// until the peer finishes the stream, onMessage returns an error, or
+	// the caller calls Cancel.
+	SendRequestStream(ctx context.Context, method string, request WritableStruct, onMessage func(ReadableStruct) error) (StreamHandle, error)
+
+	// SendRequestChannel issues a bidirectional, client- and
+	// server-driven stream: messages sent on sendCh are delivered to the
+	// peer in order, and messages the peer pushes are delivered on
+	// recvCh. SendRequestChannel blocks until the channel completes,
+	// either because sendCh is closed and the peer acknowledges EOF, or
+	// because ctx is canceled.
+	SendRequestChannel(ctx context.Context, method string, sendCh <-chan WritableStruct, recvCh chan<- ReadableStruct) error
+}
+
+// StreamHandle controls a stream started by SendRequestStream, mirroring
+// reactive-streams-style credit-based flow control: no messages are
+// delivered until the caller requests them.
+type StreamHandle interface {
+	// Cancel stops the stream and releases its resources. It is safe to
+	// call Cancel more than once and after the stream has finished.
+	Cancel()
+
+	// Request grants the peer credit to push up to n additional
+	// messages. Callers that want unbounded delivery should request a
+	// large credit up front; Done() signals completion either way.
+	Request(n int)
+
+	// Done is closed once the stream has finished, either because the
+	// peer completed it, onMessage returned an error, or Cancel was
+	// called.
+	Done() <-chan struct{}
+}
+
 // DO NOT USE: temporary migration workaround.
 type DO_NOT_USE_ChannelWrapper interface {
 	DO_NOT_USE_WrapChannel() RequestChannel
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrStreamingNotSupported is returned by a fallbackStreamingChannel's
+// SendRequestStream and SendRequestChannel, since it wraps a RequestChannel
+// with no streaming transport underneath it.
+var ErrStreamingNotSupported = errors.New("types: streaming not supported by this channel")
+
+// FallbackStreamingChannel adapts any RequestChannel into a
+// StreamingRequestChannel, so generated client code can always type-assert
+// a channel to StreamingRequestChannel and get back something usable,
+// instead of every non-streaming RequestChannel implementation (e.g. one
+// backed by a transport with no stream support) needing to grow its own
+// stub methods. If next already implements StreamingRequestChannel it is
+// returned unchanged; otherwise SendRequestResponse/SendRequestNoResponse/
+// Close delegate to next, and SendRequestStream/SendRequestChannel fail
+// with ErrStreamingNotSupported.
+func FallbackStreamingChannel(next RequestChannel) StreamingRequestChannel {
+	if sc, ok := next.(StreamingRequestChannel); ok {
+		return sc
+	}
+	return &fallbackStreamingChannel{RequestChannel: next}
+}
+
+type fallbackStreamingChannel struct {
+	RequestChannel
+}
+
+func (c *fallbackStreamingChannel) SendRequestStream(ctx context.Context, method string, request WritableStruct, onMessage func(ReadableStruct) error) (StreamHandle, error) {
+	return nil, ErrStreamingNotSupported
+}
+
+func (c *fallbackStreamingChannel) SendRequestChannel(ctx context.Context, method string, sendCh <-chan WritableStruct, recvCh chan<- ReadableStruct) error {
+	return ErrStreamingNotSupported
+}
+
+// CreditStreamHandle is a reusable, transport-agnostic StreamHandle
+// implementation using reactive-streams-style credit: Request grants
+// credit, delivered on the channel returned alongside the handle by
+// NewCreditStreamHandle, so the transport loop pushing messages can block
+// until the caller has asked for more. A concrete StreamingRequestChannel
+// (e.g. one backed by Rocket) constructs one per call to SendRequestStream
+// instead of hand-rolling its own credit/cancel/done bookkeeping.
+type CreditStreamHandle struct {
+	creditc chan int
+
+	mu     sync.Mutex
+	closed bool
+	donec  chan struct{}
+}
+
+// NewCreditStreamHandle returns a CreditStreamHandle and the channel its
+// Request calls deliver credit on. The transport reads off creditsc to
+// learn how many more messages it may push before waiting for more credit,
+// and calls Cancel once the stream ends for any reason (peer completion,
+// onMessage error, or the caller cancelling) so Done unblocks.
+func NewCreditStreamHandle() (handle *CreditStreamHandle, creditsc <-chan int) {
+	h := &CreditStreamHandle{
+		creditc: make(chan int),
+		donec:   make(chan struct{}),
+	}
+	return h, h.creditc
+}
+
+// Request grants the peer credit to push up to n additional messages. It
+// blocks until the transport reads the credit off creditsc or the stream
+// finishes, whichever comes first, and does nothing for n <= 0.
+func (h *CreditStreamHandle) Request(n int) {
+	if n <= 0 {
+		return
+	}
+	select {
+	case h.creditc <- n:
+	case <-h.donec:
+	}
+}
+
+// Cancel stops the stream and releases its resources. It is safe to call
+// more than once, concurrently, and after the stream has already finished.
+func (h *CreditStreamHandle) Cancel() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.donec)
+	}
+}
+
+// Done is closed once the stream has finished.
+func (h *CreditStreamHandle) Done() <-chan struct{} {
+	return h.donec
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) Meta Platforms, Inc. and affiliates.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeRequestChannel struct {
+	closed bool
+}
+
+func (c *fakeRequestChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeRequestChannel) SendRequestResponse(ctx context.Context, method string, request WritableStruct, response ReadableStruct) error {
+	return nil
+}
+
+func (c *fakeRequestChannel) SendRequestNoResponse(ctx context.Context, method string, request WritableStruct) error {
+	return nil
+}
+
+func TestFallbackStreamingChannelDelegatesNonStreamingCalls(t *testing.T) {
+	fake := &fakeRequestChannel{}
+	sc := FallbackStreamingChannel(fake)
+
+	if err := sc.SendRequestResponse(context.Background(), "m", nil, nil); err != nil {
+		t.Fatalf("SendRequestResponse: %v", err)
+	}
+	if err := sc.SendRequestNoResponse(context.Background(), "m", nil); err != nil {
+		t.Fatalf("SendRequestNoResponse: %v", err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !fake.closed {
+		t.Fatal("Close did not delegate to the wrapped channel")
+	}
+}
+
+func TestFallbackStreamingChannelRejectsStreamingCalls(t *testing.T) {
+	sc := FallbackStreamingChannel(&fakeRequestChannel{})
+
+	if _, err := sc.SendRequestStream(context.Background(), "m", nil, nil); !errors.Is(err, ErrStreamingNotSupported) {
+		t.Fatalf("SendRequestStream error = %v, want ErrStreamingNotSupported", err)
+	}
+	if err := sc.SendRequestChannel(context.Background(), "m", nil, nil); !errors.Is(err, ErrStreamingNotSupported) {
+		t.Fatalf("SendRequestChannel error = %v, want ErrStreamingNotSupported", err)
+	}
+}
+
+// alreadyStreaming implements StreamingRequestChannel itself, so
+// FallbackStreamingChannel must return it unchanged rather than wrapping it.
+type alreadyStreaming struct {
+	fakeRequestChannel
+}
+
+func (c *alreadyStreaming) SendRequestStream(ctx context.Context, method string, request WritableStruct, onMessage func(ReadableStruct) error) (StreamHandle, error) {
+	return nil, nil
+}
+
+func (c *alreadyStreaming) SendRequestChannel(ctx context.Context, method string, sendCh <-chan WritableStruct, recvCh chan<- ReadableStruct) error {
+	return nil
+}
+
+func TestFallbackStreamingChannelPassesThroughExistingImplementation(t *testing.T) {
+	already := &alreadyStreaming{}
+	if got := FallbackStreamingChannel(already); got != StreamingRequestChannel(already) {
+		t.Fatalf("FallbackStreamingChannel wrapped a channel that already implements StreamingRequestChannel")
+	}
+}
+
+func TestCreditStreamHandleDeliversCredit(t *testing.T) {
+	h, creditsc := NewCreditStreamHandle()
+	go h.Request(5)
+
+	select {
+	case n := <-creditsc:
+		if n != 5 {
+			t.Fatalf("credit = %d, want 5", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for credit")
+	}
+}
+
+func TestCreditStreamHandleRequestUnblocksOnCancel(t *testing.T) {
+	h, _ := NewCreditStreamHandle()
+	h.Cancel()
+
+	done := make(chan struct{})
+	go func() {
+		h.Request(1) // no reader on creditsc; must unblock via donec instead.
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Request did not unblock after Cancel")
+	}
+}
+
+func TestCreditStreamHandleCancelIsIdempotent(t *testing.T) {
+	h, _ := NewCreditStreamHandle()
+	h.Cancel()
+	h.Cancel() // must not panic on double-close
+
+	select {
+	case <-h.Done():
+	default:
+		t.Fatal("Done channel not closed after Cancel")
+	}
+}